@@ -0,0 +1,68 @@
+// This file is part of arduino-cli.
+//
+// Copyright 2020 ARDUINO SA (http://www.arduino.cc/)
+//
+// This software is released under the GNU General Public License version 3,
+// which covers the main part of arduino-cli.
+// The terms of this license can be found at:
+// https://www.gnu.org/licenses/gpl-3.0.en.html
+//
+// You can be released from the requirements of the above licenses by purchasing
+// a commercial license. Buying such a license is mandatory if you want to
+// modify or otherwise use the software for commercial activities involving the
+// Arduino software without disclosing the source code of your own applications.
+// To purchase a commercial license, send an email to license@arduino.cc.
+
+package librariesindex
+
+// Index represents the libraries available for download. Libraries maps a
+// library name to every same-named Library indexed so far, one per source
+// repository, so a third-party repository can't shadow or be shadowed by
+// another repository's library of the same name.
+type Index struct {
+	Libraries map[string][]*Library
+}
+
+// FindIndexedLibrary returns the first Library registered under name, or
+// nil if the index has none by that name. Use FindIndexedLibraryFromRepo to
+// pick a specific repository's library when more than one shares the name.
+func (i *Index) FindIndexedLibrary(name string) *Library {
+	if i == nil || len(i.Libraries[name]) == 0 {
+		return nil
+	}
+	return i.Libraries[name][0]
+}
+
+// FindIndexedLibraryFromRepo returns the Library registered under name by
+// the repository named source ("" for the official Arduino index), or nil
+// if that repository has none by that name.
+func (i *Index) FindIndexedLibraryFromRepo(name, source string) *Library {
+	if i == nil {
+		return nil
+	}
+	for _, lib := range i.Libraries[name] {
+		if lib.Source == source {
+			return lib
+		}
+	}
+	return nil
+}
+
+// Merge folds every library in other into i, tagging each one (and its
+// releases) with source. A name already present in i keeps its existing
+// entries; other's same-named library is appended alongside them rather
+// than replacing or being dropped.
+func (i *Index) Merge(other *Index, source string) {
+	if i.Libraries == nil {
+		i.Libraries = map[string][]*Library{}
+	}
+	for name, libs := range other.Libraries {
+		for _, lib := range libs {
+			lib.Source = source
+			for _, release := range lib.Releases {
+				release.Source = source
+			}
+			i.Libraries[name] = append(i.Libraries[name], lib)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+// This file is part of arduino-cli.
+//
+// Copyright 2020 ARDUINO SA (http://www.arduino.cc/)
+//
+// This software is released under the GNU General Public License version 3,
+// which covers the main part of arduino-cli.
+// The terms of this license can be found at:
+// https://www.gnu.org/licenses/gpl-3.0.en.html
+//
+// You can be released from the requirements of the above licenses by purchasing
+// a commercial license. Buying such a license is mandatory if you want to
+// modify or otherwise use the software for commercial activities involving the
+// Arduino software without disclosing the source code of your own applications.
+// To purchase a commercial license, send an email to license@arduino.cc.
+
+package librariesindex
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMergeTagsLibrariesAndReleasesWithSource(t *testing.T) {
+	official := &Index{Libraries: map[string][]*Library{
+		"ArduinoJson": {{Name: "ArduinoJson", Releases: map[string]*Release{"1.0.0": {}}}},
+	}}
+	community := &Index{Libraries: map[string][]*Library{
+		"Servo": {{Name: "Servo", Releases: map[string]*Release{"2.0.0": {}}}},
+	}}
+
+	merged := &Index{}
+	merged.Merge(official, "")
+	merged.Merge(community, "community")
+
+	require.Len(t, merged.Libraries["ArduinoJson"], 1)
+	assert.Equal(t, "", merged.Libraries["ArduinoJson"][0].Source)
+	assert.Equal(t, "", merged.Libraries["ArduinoJson"][0].Releases["1.0.0"].Source)
+
+	require.Len(t, merged.Libraries["Servo"], 1)
+	assert.Equal(t, "community", merged.Libraries["Servo"][0].Source)
+	assert.Equal(t, "community", merged.Libraries["Servo"][0].Releases["2.0.0"].Source)
+}
+
+func TestMergeKeepsSameNamedLibrariesFromDifferentRepositoriesSeparate(t *testing.T) {
+	official := &Index{Libraries: map[string][]*Library{
+		"Servo": {{Name: "Servo", Releases: map[string]*Release{"1.0.0": {}}}},
+	}}
+	community := &Index{Libraries: map[string][]*Library{
+		"Servo": {{Name: "Servo", Releases: map[string]*Release{"9.9.9": {}}}},
+	}}
+
+	merged := &Index{}
+	merged.Merge(official, "")
+	merged.Merge(community, "community")
+
+	require.Len(t, merged.Libraries["Servo"], 2, "a same-named third-party library must not shadow or replace the official one")
+
+	official1 := merged.FindIndexedLibrary("Servo")
+	require.NotNil(t, official1)
+	assert.Equal(t, "", official1.Source)
+	assert.NotNil(t, official1.GetRelease("1.0.0"))
+
+	fromOfficial := merged.FindIndexedLibraryFromRepo("Servo", "")
+	require.NotNil(t, fromOfficial)
+	assert.Same(t, official1, fromOfficial)
+
+	fromCommunity := merged.FindIndexedLibraryFromRepo("Servo", "community")
+	require.NotNil(t, fromCommunity)
+	assert.Equal(t, "community", fromCommunity.Source)
+	assert.NotNil(t, fromCommunity.GetRelease("9.9.9"))
+
+	assert.Nil(t, merged.FindIndexedLibraryFromRepo("Servo", "no-such-repo"))
+}
+
+func TestFindIndexedLibraryOnNilAndEmptyIndex(t *testing.T) {
+	var nilIndex *Index
+	assert.Nil(t, nilIndex.FindIndexedLibrary("Servo"))
+	assert.Nil(t, nilIndex.FindIndexedLibraryFromRepo("Servo", ""))
+
+	empty := &Index{}
+	assert.Nil(t, empty.FindIndexedLibrary("Servo"))
+}
@@ -0,0 +1,47 @@
+// This file is part of arduino-cli.
+//
+// Copyright 2020 ARDUINO SA (http://www.arduino.cc/)
+//
+// This software is released under the GNU General Public License version 3,
+// which covers the main part of arduino-cli.
+// The terms of this license can be found at:
+// https://www.gnu.org/licenses/gpl-3.0.en.html
+//
+// You can be released from the requirements of the above licenses by purchasing
+// a commercial license. Buying such a license is mandatory if you want to
+// modify or otherwise use the software for commercial activities involving the
+// Arduino software without disclosing the source code of your own applications.
+// To purchase a commercial license, send an email to license@arduino.cc.
+
+package librariesindex
+
+// Library is a library as listed in an index: a name and every version
+// released for it, keyed by version string. Source is the name of the
+// repository this Library was merged in from by Index.Merge ("" for the
+// official Arduino index).
+type Library struct {
+	Name     string
+	Source   string
+	Releases map[string]*Release
+	Latest   *Release
+}
+
+// GetReleases returns every indexed release of this library, in no particular order.
+func (l *Library) GetReleases() []*Release {
+	if l == nil {
+		return nil
+	}
+	releases := make([]*Release, 0, len(l.Releases))
+	for _, release := range l.Releases {
+		releases = append(releases, release)
+	}
+	return releases
+}
+
+// GetRelease returns the release matching version, or nil if none is indexed.
+func (l *Library) GetRelease(version string) *Release {
+	if l == nil {
+		return nil
+	}
+	return l.Releases[version]
+}
@@ -0,0 +1,76 @@
+// This file is part of arduino-cli.
+//
+// Copyright 2020 ARDUINO SA (http://www.arduino.cc/)
+//
+// This software is released under the GNU General Public License version 3,
+// which covers the main part of arduino-cli.
+// The terms of this license can be found at:
+// https://www.gnu.org/licenses/gpl-3.0.en.html
+//
+// You can be released from the requirements of the above licenses by purchasing
+// a commercial license. Buying such a license is mandatory if you want to
+// modify or otherwise use the software for commercial activities involving the
+// Arduino software without disclosing the source code of your own applications.
+// To purchase a commercial license, send an email to license@arduino.cc.
+
+package librariesindex
+
+import (
+	"encoding/json"
+	"fmt"
+
+	paths "github.com/arduino/go-paths-helper"
+	semver "go.bug.st/relaxed-semver"
+)
+
+// indexJSON is the on-disk shape of a library_index.json: a flat list with
+// one entry per released version.
+type indexJSON struct {
+	Libraries []releaseJSON `json:"libraries"`
+}
+
+type releaseJSON struct {
+	Name         string           `json:"name"`
+	Version      string           `json:"version"`
+	Dependencies []dependencyJSON `json:"dependencies,omitempty"`
+}
+
+type dependencyJSON struct {
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+}
+
+// LoadIndex reads and parses a library_index.json file, grouping its flat
+// list of per-version entries into one Library per name.
+func LoadIndex(indexFile *paths.Path) (*Index, error) {
+	data, err := indexFile.ReadFile()
+	if err != nil {
+		return nil, fmt.Errorf("reading library index: %w", err)
+	}
+	raw := &indexJSON{}
+	if err := json.Unmarshal(data, raw); err != nil {
+		return nil, fmt.Errorf("parsing library index: %w", err)
+	}
+
+	index := &Index{Libraries: map[string][]*Library{}}
+	for _, entry := range raw.Libraries {
+		libs := index.Libraries[entry.Name]
+		var lib *Library
+		if len(libs) > 0 {
+			lib = libs[0]
+		} else {
+			lib = &Library{Name: entry.Name, Releases: map[string]*Release{}}
+			index.Libraries[entry.Name] = []*Library{lib}
+		}
+
+		release := &Release{Library: lib, Version: semver.ParseRelaxed(entry.Version)}
+		for _, dep := range entry.Dependencies {
+			release.Dependencies = append(release.Dependencies, &Dependency{Name: dep.Name, VersionConstraint: dep.Version})
+		}
+		lib.Releases[entry.Version] = release
+		if lib.Latest == nil || release.Version.GreaterThan(lib.Latest.Version) {
+			lib.Latest = release
+		}
+	}
+	return index, nil
+}
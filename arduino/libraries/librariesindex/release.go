@@ -0,0 +1,39 @@
+// This file is part of arduino-cli.
+//
+// Copyright 2020 ARDUINO SA (http://www.arduino.cc/)
+//
+// This software is released under the GNU General Public License version 3,
+// which covers the main part of arduino-cli.
+// The terms of this license can be found at:
+// https://www.gnu.org/licenses/gpl-3.0.en.html
+//
+// You can be released from the requirements of the above licenses by purchasing
+// a commercial license. Buying such a license is mandatory if you want to
+// modify or otherwise use the software for commercial activities involving the
+// Arduino software without disclosing the source code of your own applications.
+// To purchase a commercial license, send an email to license@arduino.cc.
+
+package librariesindex
+
+import (
+	"github.com/arduino/arduino-cli/arduino/resources"
+	semver "go.bug.st/relaxed-semver"
+)
+
+// Release is a single released version of a Library, as listed in the index.
+type Release struct {
+	Library      *Library
+	Version      *semver.Version
+	Dependencies []*Dependency
+	Resource     *resources.DownloadResource
+
+	// Source is the name of the repository this release was merged from by
+	// Index.Merge ("" for the official Arduino index).
+	Source string
+}
+
+// Dependency is a single entry of a library's library.properties "depends=" field.
+type Dependency struct {
+	Name              string
+	VersionConstraint string
+}
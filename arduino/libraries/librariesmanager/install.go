@@ -17,8 +17,12 @@ package librariesmanager
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"net/url"
 	"os"
 	"strings"
@@ -30,12 +34,32 @@ import (
 	"github.com/codeclysm/extract/v3"
 	"github.com/sirupsen/logrus"
 	"gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing"
 )
 
+// gitLibraryMetadataFileName is the sidecar file InstallGitLib writes into
+// every git-installed library so its origin and resolved commit can be
+// reported even after .git has been stripped out.
+const gitLibraryMetadataFileName = "library.json"
+
+// gitLibraryMetadata is the content of gitLibraryMetadataFileName.
+type gitLibraryMetadata struct {
+	Origin string `json:"origin"`
+	Ref    string `json:"ref,omitempty"`
+	Commit string `json:"commit"`
+}
+
 var (
 	// ErrAlreadyInstalled is returned when a library is already installed and task
 	// cannot proceed.
 	ErrAlreadyInstalled = errors.New("library already installed")
+
+	// ErrIntegrityMismatch is returned when an installed library's bytes don't
+	// match what was expected: a zip's SHA-256 doesn't match, or a git ref's
+	// signature doesn't verify against the configured keyring. It is kept
+	// distinct from network/extract errors so callers can prompt the user to
+	// confirm instead of failing silently.
+	ErrIntegrityMismatch = errors.New("integrity verification failed")
 )
 
 // InstallPrerequisiteCheck performs prequisite checks to install a library. It returns the
@@ -93,8 +117,10 @@ func (lm *LibrariesManager) Uninstall(lib *libraries.Library) error {
 	return nil
 }
 
-//InstallZipLib  installs a Zip library on the specified path.
-func (lm *LibrariesManager) InstallZipLib(ctx context.Context, archivePath string) error {
+//InstallZipLib  installs a Zip library on the specified path. If expectedSHA256 is not
+// empty the archive's checksum is verified before extraction and ErrIntegrityMismatch is
+// returned on mismatch.
+func (lm *LibrariesManager) InstallZipLib(ctx context.Context, archivePath string, expectedSHA256 string) error {
 	libsDir := lm.getUserLibrariesDir()
 	if libsDir == nil {
 		return fmt.Errorf("User directory not set")
@@ -111,6 +137,15 @@ func (lm *LibrariesManager) InstallZipLib(ctx context.Context, archivePath strin
 	}
 	defer file.Close()
 
+	if expectedSHA256 != "" {
+		if err := verifyFileSHA256(file, expectedSHA256); err != nil {
+			return err
+		}
+		if _, err := file.Seek(0, io.SeekStart); err != nil {
+			return fmt.Errorf("rewinding archive after checksum verification: %w", err)
+		}
+	}
+
 	// Extract to a temporary directory so we can check if the zip is structured correctly.
 	// We also use the top level folder from the archive to infer the library name.
 	if err := extract.Archive(ctx, file, tmpDir.String(), nil); err != nil {
@@ -151,13 +186,19 @@ func (lm *LibrariesManager) InstallZipLib(ctx context.Context, archivePath strin
 	return nil
 }
 
-//InstallGitLib  installs a library hosted on a git repository on the specified path.
-func (lm *LibrariesManager) InstallGitLib(gitURL string) error {
+//InstallGitLib  installs a library hosted on a git repository on the specified path. spec
+// is a gitURL, optionally suffixed with "@ref" where ref is a tag, branch or commit SHA
+// to pin to; if no ref is given the default branch is installed. Unless keepVCS is set
+// the .git folder is stripped from the installed library once checkout completes. If
+// trustedKeysFile is not nil the checked out tag or commit must carry a valid signature
+// against that armored keyring, or ErrIntegrityMismatch is returned and nothing is installed.
+func (lm *LibrariesManager) InstallGitLib(spec string, recurseSubmodules, keepVCS bool, trustedKeysFile *paths.Path) error {
 	libsDir := lm.getUserLibrariesDir()
 	if libsDir == nil {
 		return fmt.Errorf("User directory not set")
 	}
 
+	gitURL, ref := parseGitSpec(spec)
 	libraryName, err := parseGitURL(gitURL)
 	if err != nil {
 		logrus.
@@ -181,24 +222,264 @@ func (lm *LibrariesManager) InstallGitLib(gitURL string) error {
 		WithField("library name", libraryName).
 		WithField("install path", installPath).
 		WithField("git url", gitURL).
+		WithField("ref", ref).
 		Trace("Installing library")
 
-	_, err = git.PlainClone(installPath.String(), false, &git.CloneOptions{
+	cloneOpts := &git.CloneOptions{
 		URL:      gitURL,
-		Depth:    1,
 		Progress: os.Stdout,
-	})
+	}
+	if recurseSubmodules {
+		cloneOpts.RecurseSubmodules = git.DefaultSubmoduleRecursionDepth
+	}
+	if ref == "" {
+		// No ref requested: a shallow depth-1 clone of the default branch is enough.
+		cloneOpts.Depth = 1
+	}
+
+	repo, err := git.PlainClone(installPath.String(), false, cloneOpts)
 	if err != nil {
 		logrus.
 			WithError(err).
 			Warn("Cloning git repository")
 		return err
 	}
-	// We don't want the installed library to be a git repository thus we delete this folder
-	installPath.Join(".git").RemoveAll()
+	// Anything that fails from here on leaves a clone that is neither usable nor
+	// known to lm.Libraries yet, so clean it up rather than leaving it orphaned.
+	installOK := false
+	defer func() {
+		if !installOK {
+			installPath.RemoveAll()
+		}
+	}()
+
+	if ref != "" {
+		hash, err := resolveRef(repo, ref)
+		if err != nil {
+			return err
+		}
+		wt, err := repo.Worktree()
+		if err != nil {
+			return err
+		}
+		if err := wt.Checkout(&git.CheckoutOptions{Hash: hash}); err != nil {
+			return fmt.Errorf("checking out %s: %w", ref, err)
+		}
+	}
+
+	if trustedKeysFile != nil {
+		if err := verifyGitRef(repo, ref, trustedKeysFile); err != nil {
+			return fmt.Errorf("%w: %s", ErrIntegrityMismatch, err)
+		}
+	}
+
+	head, err := repo.Head()
+	if err != nil {
+		return fmt.Errorf("resolving installed commit: %w", err)
+	}
+	if err := writeGitLibraryMetadata(installPath, gitURL, ref, head.Hash().String()); err != nil {
+		return fmt.Errorf("writing library metadata: %w", err)
+	}
+
+	if !keepVCS {
+		// We don't want the installed library to be a git repository thus we delete this folder
+		installPath.Join(".git").RemoveAll()
+	}
+	installOK = true
 	return nil
 }
 
+// UpdateGitLib fetches and checks out the latest commit for the ref a library
+// was pinned to (or the default branch, if it wasn't pinned) for a library
+// previously installed with InstallGitLib and keepVCS set. Libraries installed
+// without keepVCS have no working repository to update and must be reinstalled.
+func (lm *LibrariesManager) UpdateGitLib(lib *libraries.Library) error {
+	if lib.InstallDir == nil {
+		return fmt.Errorf("install directory not set")
+	}
+
+	repo, err := git.PlainOpen(lib.InstallDir.String())
+	if err != nil {
+		return fmt.Errorf("%s was not installed with --keep-vcs, reinstall it to update it: %w", lib.Name, err)
+	}
+
+	meta, err := readGitLibraryMetadata(lib.InstallDir)
+	if err != nil {
+		return err
+	}
+
+	if err := repo.Fetch(&git.FetchOptions{Progress: os.Stdout}); err != nil && err != git.NoErrAlreadyUpToDate {
+		return fmt.Errorf("fetching updates: %w", err)
+	}
+
+	ref := meta.Ref
+	if ref == "" {
+		branch, err := resolveDefaultBranch(repo)
+		if err != nil {
+			return fmt.Errorf("resolving default branch: %w", err)
+		}
+		ref = branch
+	}
+	hash, err := resolveRef(repo, ref)
+	if err != nil {
+		return err
+	}
+	wt, err := repo.Worktree()
+	if err != nil {
+		return err
+	}
+	if err := wt.Checkout(&git.CheckoutOptions{Hash: hash}); err != nil {
+		return fmt.Errorf("checking out %s: %w", ref, err)
+	}
+
+	head, err := repo.Head()
+	if err != nil {
+		return fmt.Errorf("resolving updated commit: %w", err)
+	}
+	return writeGitLibraryMetadata(lib.InstallDir, meta.Origin, meta.Ref, head.Hash().String())
+}
+
+// verifyGitRef checks that the ref checked out in repo (or, if ref is empty,
+// the current HEAD) carries a valid signature against trustedKeysFile. If ref
+// names a tag, that tag's own signature is checked and a lightweight tag is
+// rejected; otherwise the signature on the HEAD commit is checked.
+func verifyGitRef(repo *git.Repository, ref string, trustedKeysFile *paths.Path) error {
+	keyring, err := trustedKeysFile.ReadFile()
+	if err != nil {
+		return fmt.Errorf("reading trusted keyring: %w", err)
+	}
+
+	if ref != "" {
+		if tagRef, err := repo.Tag(ref); err == nil {
+			tagObj, err := repo.TagObject(tagRef.Hash())
+			if err != nil {
+				return fmt.Errorf("%s is not a signed annotated tag", ref)
+			}
+			if _, err := tagObj.Verify(string(keyring)); err != nil {
+				return fmt.Errorf("verifying tag signature: %w", err)
+			}
+			return nil
+		}
+	}
+
+	head, err := repo.Head()
+	if err != nil {
+		return err
+	}
+	commit, err := repo.CommitObject(head.Hash())
+	if err != nil {
+		return err
+	}
+	if _, err := commit.Verify(string(keyring)); err != nil {
+		return fmt.Errorf("verifying commit signature: %w", err)
+	}
+	return nil
+}
+
+// verifyFileSHA256 checks that file's contents hash to the given expected
+// SHA-256 (hex-encoded, case-insensitive), returning ErrIntegrityMismatch if not.
+func verifyFileSHA256(file *os.File, expected string) error {
+	h := sha256.New()
+	if _, err := io.Copy(h, file); err != nil {
+		return fmt.Errorf("hashing archive: %w", err)
+	}
+	if actual := hex.EncodeToString(h.Sum(nil)); !strings.EqualFold(actual, expected) {
+		return fmt.Errorf("%w: expected sha256 %s, got %s", ErrIntegrityMismatch, expected, actual)
+	}
+	return nil
+}
+
+// resolveRef turns a tag, branch or commit SHA into the commit hash it points at.
+// An annotated tag's reference points at the tag object, not the commit, so it is
+// dereferenced one step further; a lightweight tag already points straight at a commit.
+func resolveRef(repo *git.Repository, ref string) (plumbing.Hash, error) {
+	if tagRef, err := repo.Tag(ref); err == nil {
+		if tagObj, err := repo.TagObject(tagRef.Hash()); err == nil {
+			commit, err := tagObj.Commit()
+			if err != nil {
+				return plumbing.ZeroHash, fmt.Errorf("resolving tag %s to a commit: %w", ref, err)
+			}
+			return commit.Hash, nil
+		}
+		// Not an annotated tag object: it's a lightweight tag pointing directly at a commit.
+		return tagRef.Hash(), nil
+	}
+	if branchRef, err := repo.Reference(plumbing.NewRemoteReferenceName("origin", ref), true); err == nil {
+		return branchRef.Hash(), nil
+	}
+	if hash, err := repo.ResolveRevision(plumbing.Revision(ref)); err == nil {
+		return *hash, nil
+	}
+	return plumbing.ZeroHash, fmt.Errorf("could not resolve git ref %q", ref)
+}
+
+// resolveDefaultBranch asks the origin remote directly which branch its HEAD
+// points at, rather than assuming a local refs/remotes/origin/HEAD exists: a
+// plain clone doesn't reliably create one, and resolveRef's branch lookup
+// already prefixes whatever ref it's given with "refs/remotes/origin/", so
+// synthesizing the literal ref "origin/HEAD" would look up
+// "refs/remotes/origin/origin/HEAD" and never match.
+func resolveDefaultBranch(repo *git.Repository) (string, error) {
+	remote, err := repo.Remote("origin")
+	if err != nil {
+		return "", fmt.Errorf("looking up origin remote: %w", err)
+	}
+	refs, err := remote.List(&git.ListOptions{})
+	if err != nil {
+		return "", fmt.Errorf("listing origin refs: %w", err)
+	}
+	for _, ref := range refs {
+		if ref.Name() == plumbing.HEAD && ref.Type() == plumbing.SymbolicReference {
+			return ref.Target().Short(), nil
+		}
+	}
+	return "", fmt.Errorf("origin did not advertise a default branch")
+}
+
+// writeGitLibraryMetadata writes the library.json sidecar recording where a
+// git-installed library came from, so lib list / gRPC responses can report
+// the true installed version instead of "unknown".
+func writeGitLibraryMetadata(installPath *paths.Path, origin, ref, commit string) error {
+	data, err := json.MarshalIndent(&gitLibraryMetadata{Origin: origin, Ref: ref, Commit: commit}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return installPath.Join(gitLibraryMetadataFileName).WriteFile(data)
+}
+
+// readGitLibraryMetadata reads back the sidecar written by writeGitLibraryMetadata.
+func readGitLibraryMetadata(installPath *paths.Path) (*gitLibraryMetadata, error) {
+	data, err := installPath.Join(gitLibraryMetadataFileName).ReadFile()
+	if err != nil {
+		return nil, fmt.Errorf("reading library metadata: %w", err)
+	}
+	meta := &gitLibraryMetadata{}
+	if err := json.Unmarshal(data, meta); err != nil {
+		return nil, fmt.Errorf("parsing library metadata: %w", err)
+	}
+	return meta, nil
+}
+
+// parseGitSpec splits a "gitURL[@ref]" spec into the bare git URL and the
+// optional ref. The "git@" SSH-style prefix is not mistaken for a ref
+// separator, and neither is a "user:pass@host" userinfo section: only an "@"
+// found after the last "/" (i.e. in the final path segment, where a ref
+// suffix would be) is treated as the ref separator.
+func parseGitSpec(spec string) (gitURL, ref string) {
+	rest := spec
+	prefix := ""
+	if strings.HasPrefix(rest, "git@") {
+		prefix = "git@"
+		rest = rest[len(prefix):]
+	}
+	tailStart := strings.LastIndex(rest, "/") + 1
+	if i := strings.LastIndex(rest[tailStart:], "@"); i >= 0 {
+		i += tailStart
+		return prefix + rest[:i], rest[i+1:]
+	}
+	return prefix + rest, ""
+}
+
 func parseGitURL(gitURL string) (string, error) {
 	var res string
 	if strings.HasPrefix(gitURL, "git@") {
@@ -0,0 +1,65 @@
+// This file is part of arduino-cli.
+//
+// Copyright 2020 ARDUINO SA (http://www.arduino.cc/)
+//
+// This software is released under the GNU General Public License version 3,
+// which covers the main part of arduino-cli.
+// The terms of this license can be found at:
+// https://www.gnu.org/licenses/gpl-3.0.en.html
+//
+// You can be released from the requirements of the above licenses by purchasing
+// a commercial license. Buying such a license is mandatory if you want to
+// modify or otherwise use the software for commercial activities involving the
+// Arduino software without disclosing the source code of your own applications.
+// To purchase a commercial license, send an email to license@arduino.cc.
+
+package librariesmanager
+
+import (
+	"github.com/arduino/arduino-cli/arduino/libraries"
+	"github.com/arduino/arduino-cli/arduino/libraries/librariesindex"
+	paths "github.com/arduino/go-paths-helper"
+)
+
+// LibrariesManager keeps track of the libraries installed into a user's
+// sketchbook and the library index (or indexes) available for download.
+type LibrariesManager struct {
+	// LibrariesDir is the sketchbook "libraries" directory to install into.
+	LibrariesDir *paths.Path
+
+	// Libraries indexes every installed library, keyed by its sanitized folder name.
+	Libraries map[string]*libraries.List
+
+	// DownloadsDir caches a release archive before it's extracted into LibrariesDir.
+	DownloadsDir *paths.Path
+
+	// Index is the official Arduino library index, merged at load time with
+	// every repository in Repositories.
+	Index *librariesindex.Index
+
+	// Repositories lists the additional third-party library indexes
+	// registered via AddRepository, in the order they were added.
+	Repositories []*LibraryRepository
+
+	// IndexesDir caches each repository's library_index.json, one subdir per repository name.
+	IndexesDir *paths.Path
+}
+
+// NewLibraryManager creates a LibrariesManager that installs libraries into
+// librariesDir, caching downloads under downloadsDir and repository indexes
+// under indexesDir.
+func NewLibraryManager(librariesDir, downloadsDir, indexesDir *paths.Path) *LibrariesManager {
+	return &LibrariesManager{
+		LibrariesDir: librariesDir,
+		Libraries:    map[string]*libraries.List{},
+		DownloadsDir: downloadsDir,
+		Index:        &librariesindex.Index{},
+		IndexesDir:   indexesDir,
+	}
+}
+
+// getUserLibrariesDir returns the directory new libraries should be
+// installed into, or nil if none is configured.
+func (lm *LibrariesManager) getUserLibrariesDir() *paths.Path {
+	return lm.LibrariesDir
+}
@@ -0,0 +1,457 @@
+// This file is part of arduino-cli.
+//
+// Copyright 2020 ARDUINO SA (http://www.arduino.cc/)
+//
+// This software is released under the GNU General Public License version 3,
+// which covers the main part of arduino-cli.
+// The terms of this license can be found at:
+// https://www.gnu.org/licenses/gpl-3.0.en.html
+//
+// You can be released from the requirements of the above licenses by purchasing
+// a commercial license. Buying such a license is mandatory if you want to
+// modify or otherwise use the software for commercial activities involving the
+// Arduino software without disclosing the source code of your own applications.
+// To purchase a commercial license, send an email to license@arduino.cc.
+
+package librariesmanager
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/arduino/arduino-cli/arduino/libraries/librariesindex"
+	paths "github.com/arduino/go-paths-helper"
+	"github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v2"
+)
+
+// ManifestLibrary is a single library requirement listed in a manifest file.
+// Exactly one of Constraint, GitURL or ZipPath identifies where the library
+// comes from; Name is always required so dependency edges can reference it.
+type ManifestLibrary struct {
+	Name       string `yaml:"name"`
+	Constraint string `yaml:"version,omitempty"`
+	GitURL     string `yaml:"git,omitempty"`
+	Ref        string `yaml:"ref,omitempty"`
+	ZipPath    string `yaml:"zip,omitempty"`
+}
+
+// Manifest is the user-authored list of libraries a sketch depends on.
+type Manifest struct {
+	Libraries []*ManifestLibrary `yaml:"libraries"`
+}
+
+// LockedLibrary is a single fully-resolved entry in a Lockfile.
+type LockedLibrary struct {
+	Name      string `yaml:"name"`
+	Version   string `yaml:"version"`
+	GitURL    string `yaml:"git,omitempty"`
+	GitSHA    string `yaml:"git_sha,omitempty"`
+	ZipPath   string `yaml:"zip,omitempty"`
+	ZipSHA256 string `yaml:"zip_sha256,omitempty"`
+}
+
+// Lockfile pins the exact version (and, where applicable, the exact git
+// commit or zip checksum) of every library resolved from a Manifest. It is
+// meant to be checked into version control alongside the manifest so that
+// InstallFromManifest can replay the very same install on any machine.
+type Lockfile struct {
+	Libraries []*LockedLibrary `yaml:"libraries"`
+}
+
+// ErrDependencyConflict is returned by ResolveManifest when no single
+// version of a library can satisfy every constraint requested for it. Chain
+// lists, in order, each requirement that contributed to the conflict so the
+// caller can show the user the full picture instead of just the first two
+// constraints that happened to clash.
+type ErrDependencyConflict struct {
+	Library string
+	Chain   []string
+}
+
+func (e *ErrDependencyConflict) Error() string {
+	return fmt.Sprintf("no version of %s satisfies all requirements: %s", e.Library, strings.Join(e.Chain, ", "))
+}
+
+// requirement is one constraint placed on a library, together with the name
+// of whatever introduced it (the manifest itself, or another library's
+// library.properties depends= field), so it can be reported in an
+// ErrDependencyConflict.
+type requirement struct {
+	constraint string
+	origin     string
+}
+
+// LoadManifest reads and parses a library manifest in YAML format.
+func LoadManifest(manifestPath *paths.Path) (*Manifest, error) {
+	data, err := manifestPath.ReadFile()
+	if err != nil {
+		return nil, fmt.Errorf("reading manifest: %w", err)
+	}
+	manifest := &Manifest{}
+	if err := yaml.Unmarshal(data, manifest); err != nil {
+		return nil, fmt.Errorf("parsing manifest: %w", err)
+	}
+	return manifest, nil
+}
+
+// LoadLockfile reads and parses a previously generated Lockfile.
+func LoadLockfile(lockfilePath *paths.Path) (*Lockfile, error) {
+	data, err := lockfilePath.ReadFile()
+	if err != nil {
+		return nil, fmt.Errorf("reading lockfile: %w", err)
+	}
+	lockfile := &Lockfile{}
+	if err := yaml.Unmarshal(data, lockfile); err != nil {
+		return nil, fmt.Errorf("parsing lockfile: %w", err)
+	}
+	return lockfile, nil
+}
+
+// Save writes the lockfile to lockfilePath in the same YAML format LoadLockfile expects.
+func (l *Lockfile) Save(lockfilePath *paths.Path) error {
+	data, err := yaml.Marshal(l)
+	if err != nil {
+		return fmt.Errorf("encoding lockfile: %w", err)
+	}
+	if err := lockfilePath.WriteFile(data); err != nil {
+		return fmt.Errorf("writing lockfile: %w", err)
+	}
+	return nil
+}
+
+// checkGitPin compares the commit actually checked out for a library against
+// the one a lockfile pinned it to. An empty expectedSHA means the entry
+// wasn't pinned to a commit, so there's nothing to check.
+func checkGitPin(name, expectedSHA, actualSHA string) error {
+	if expectedSHA == "" || strings.EqualFold(expectedSHA, actualSHA) {
+		return nil
+	}
+	return fmt.Errorf("%w: lockfile pins %s at %s, got %s", ErrIntegrityMismatch, name, expectedSHA, actualSHA)
+}
+
+// sha256OfFile returns the hex-encoded SHA-256 digest of the file at path.
+func sha256OfFile(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// lockfilePathFor returns the companion lockfile path for a given manifest,
+// e.g. "arduino-libs.yaml" -> "arduino-libs.yaml.lock".
+func lockfilePathFor(manifestPath *paths.Path) *paths.Path {
+	return paths.New(manifestPath.String() + ".lock")
+}
+
+// ResolveManifest walks the dependency graph rooted at manifest and returns
+// the Lockfile that would be produced by an install, without touching disk.
+// It is deliberately side-effect free so it can be called from gRPC to let
+// an IDE preview a resolution before the user commits to it.
+func (lm *LibrariesManager) ResolveManifest(ctx context.Context, manifest *Manifest) (*Lockfile, error) {
+	requirements := map[string][]requirement{}
+	var order []string
+	pinnedOrdered := map[string]bool{}
+	pinned := map[string]*ManifestLibrary{}
+
+	// Collect every explicit git/zip pin up front, before walking the graph,
+	// so a transitively-discovered depends= entry sharing a pinned name is
+	// recognized regardless of visit order.
+	for _, entry := range manifest.Libraries {
+		if entry.GitURL != "" || entry.ZipPath != "" {
+			pinned[entry.Name] = entry
+		}
+	}
+
+	var walk func(entry *ManifestLibrary, origin string) error
+	walk = func(entry *ManifestLibrary, origin string) error {
+		if _, isPinned := pinned[entry.Name]; isPinned {
+			if !pinnedOrdered[entry.Name] {
+				pinnedOrdered[entry.Name] = true
+				order = append(order, entry.Name)
+			}
+			return nil
+		}
+
+		if _, seen := requirements[entry.Name]; !seen {
+			order = append(order, entry.Name)
+		}
+
+		constraint := entry.Constraint
+		if constraint == "" {
+			constraint = "*"
+		}
+		requirements[entry.Name] = append(requirements[entry.Name], requirement{constraint: constraint, origin: origin})
+
+		release, err := lm.findBestRelease(entry.Name, requirements[entry.Name])
+		if err != nil {
+			return err
+		}
+
+		for _, dep := range release.Dependencies {
+			depConstraint := dep.VersionConstraint
+			if depConstraint == "" {
+				depConstraint = "*"
+			}
+			if err := walk(&ManifestLibrary{Name: dep.Name, Constraint: depConstraint}, entry.Name); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	for _, entry := range manifest.Libraries {
+		if err := walk(entry, "manifest"); err != nil {
+			return nil, err
+		}
+	}
+
+	lock := &Lockfile{}
+	for _, name := range order {
+		if entry, ok := pinned[name]; ok {
+			locked := &LockedLibrary{Name: name, GitURL: entry.GitURL, GitSHA: entry.Ref, ZipPath: entry.ZipPath}
+			if entry.ZipPath != "" {
+				sha, err := sha256OfFile(entry.ZipPath)
+				if err != nil {
+					return nil, fmt.Errorf("hashing %s: %w", entry.ZipPath, err)
+				}
+				locked.ZipSHA256 = sha
+			}
+			// entry.Ref (a tag, branch or commit SHA) is carried over as-is; it is
+			// resolved to the exact commit actually checked out once the library is
+			// installed, so the lockfile can be replayed verbatim afterwards.
+			lock.Libraries = append(lock.Libraries, locked)
+			continue
+		}
+		release, err := lm.findBestRelease(name, requirements[name])
+		if err != nil {
+			return nil, err
+		}
+		lock.Libraries = append(lock.Libraries, &LockedLibrary{Name: name, Version: release.Version.String()})
+	}
+	return lock, nil
+}
+
+// findBestRelease picks the highest version of library name that satisfies
+// every requirement gathered for it so far, so resolution is deterministic
+// regardless of the order Library.GetReleases() happens to return them in.
+// If no release satisfies all requirements, it returns an
+// ErrDependencyConflict carrying the full chain of requirements instead of
+// just the first two that disagreed.
+func (lm *LibrariesManager) findBestRelease(name string, reqs []requirement) (*librariesindex.Release, error) {
+	lib := lm.Index.FindIndexedLibrary(name)
+	best, chain := pickBestRelease(lib.GetReleases(), reqs)
+	if best != nil {
+		return best, nil
+	}
+	return nil, &ErrDependencyConflict{Library: name, Chain: chain}
+}
+
+// pickBestRelease returns the highest version among releases that satisfies
+// every requirement in reqs. If none does, it returns a nil release together
+// with the full, formatted chain of requirements that contributed to the
+// conflict, so the caller can report more than just the first mismatch.
+func pickBestRelease(releases []*librariesindex.Release, reqs []requirement) (*librariesindex.Release, []string) {
+	var best *librariesindex.Release
+	for _, release := range releases {
+		satisfiesAll := true
+		for _, req := range reqs {
+			if !versionSatisfies(release.Version.String(), req.constraint) {
+				satisfiesAll = false
+				break
+			}
+		}
+		if !satisfiesAll {
+			continue
+		}
+		if best == nil || compareVersions(release.Version.String(), best.Version.String()) > 0 {
+			best = release
+		}
+	}
+	if best != nil {
+		return best, nil
+	}
+
+	chain := make([]string, 0, len(reqs))
+	for _, req := range reqs {
+		chain = append(chain, fmt.Sprintf("%s (required by %s)", req.constraint, req.origin))
+	}
+	return nil, chain
+}
+
+// versionSatisfies reports whether version meets constraint. constraint is
+// either "*" (any version), an exact version, or one of ">=", "<=", ">", "<",
+// "==" or "^" (same major, greater-or-equal) followed by a version.
+func versionSatisfies(version, constraint string) bool {
+	constraint = strings.TrimSpace(constraint)
+	if constraint == "" || constraint == "*" {
+		return true
+	}
+	for _, op := range []string{">=", "<=", "==", "^", ">", "<"} {
+		target := strings.TrimPrefix(constraint, op)
+		if target == constraint {
+			continue // constraint didn't start with op
+		}
+		target = strings.TrimSpace(target)
+		cmp := compareVersions(version, target)
+		switch op {
+		case ">=":
+			return cmp >= 0
+		case "<=":
+			return cmp <= 0
+		case ">":
+			return cmp > 0
+		case "<":
+			return cmp < 0
+		case "==":
+			return cmp == 0
+		case "^":
+			return cmp >= 0 && sameMajor(version, target)
+		}
+	}
+	return compareVersions(version, constraint) == 0
+}
+
+// sameMajor reports whether a and b share the same leading (major) version component.
+func sameMajor(a, b string) bool {
+	major := func(v string) string {
+		if i := strings.Index(v, "."); i >= 0 {
+			return v[:i]
+		}
+		return v
+	}
+	return major(a) == major(b)
+}
+
+// compareVersions compares two dot-separated version strings numerically
+// component by component, returning -1, 0 or 1. Non-numeric components are
+// compared as 0 so malformed versions don't panic. It backs both the
+// constraint checks in versionSatisfies and the choice of the highest
+// satisfying release in pickBestRelease; it does not parse semver ranges.
+func compareVersions(a, b string) int {
+	as := strings.Split(a, ".")
+	bs := strings.Split(b, ".")
+	for i := 0; i < len(as) || i < len(bs); i++ {
+		var an, bn int
+		if i < len(as) {
+			an, _ = strconv.Atoi(as[i])
+		}
+		if i < len(bs) {
+			bn, _ = strconv.Atoi(bs[i])
+		}
+		if an != bn {
+			if an > bn {
+				return 1
+			}
+			return -1
+		}
+	}
+	return 0
+}
+
+// InstallFromManifest resolves (or replays) the dependencies listed in the
+// manifest at manifestPath and installs every one of them. If a lockfile
+// already sits next to the manifest it is replayed verbatim so the install
+// is reproducible across machines; otherwise the graph is resolved fresh and
+// the resulting lockfile is written out for next time.
+func (lm *LibrariesManager) InstallFromManifest(ctx context.Context, manifestPath string) (*Lockfile, error) {
+	manifestFile := paths.New(manifestPath)
+	manifest, err := LoadManifest(manifestFile)
+	if err != nil {
+		return nil, err
+	}
+
+	lockFile := lockfilePathFor(manifestFile)
+	replaying := lockFile.Exist()
+	var lock *Lockfile
+	if replaying {
+		logrus.WithField("lockfile", lockFile).Trace("Replaying existing lockfile")
+		lock, err = LoadLockfile(lockFile)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		lock, err = lm.ResolveManifest(ctx, manifest)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for _, entry := range lock.Libraries {
+		if err := lm.installLocked(ctx, entry, replaying); err != nil {
+			return nil, fmt.Errorf("installing %s: %w", entry.Name, err)
+		}
+	}
+
+	// Freshly resolved git refs (tags/branches) are pinned down to the exact
+	// commit that was actually checked out, so replaying this lockfile later
+	// reproduces this install instead of whatever that ref points to by then.
+	if !replaying {
+		if err := lock.Save(lockFile); err != nil {
+			return nil, err
+		}
+	}
+	return lock, nil
+}
+
+// installLocked installs a single resolved lockfile entry through whichever
+// install path matches how it was sourced. When verifyPin is true (replaying
+// an existing lockfile) the commit actually checked out is required to match
+// entry.GitSHA exactly; when false (a fresh resolution) entry.GitSHA is
+// instead updated in place to the commit that was checked out, so the caller
+// can persist an exact pin for next time.
+func (lm *LibrariesManager) installLocked(ctx context.Context, entry *LockedLibrary, verifyPin bool) error {
+	switch {
+	case entry.GitURL != "":
+		spec := entry.GitURL
+		if entry.GitSHA != "" {
+			spec = fmt.Sprintf("%s@%s", spec, entry.GitSHA)
+		}
+		if err := lm.InstallGitLib(spec, false, false, nil); err != nil {
+			return err
+		}
+		libraryName, err := parseGitURL(entry.GitURL)
+		if err != nil {
+			return err
+		}
+		meta, err := readGitLibraryMetadata(lm.getUserLibrariesDir().Join(libraryName))
+		if err != nil {
+			return err
+		}
+		if verifyPin {
+			if err := checkGitPin(entry.Name, entry.GitSHA, meta.Commit); err != nil {
+				return err
+			}
+		}
+		entry.GitSHA = meta.Commit
+		return nil
+	case entry.ZipPath != "":
+		return lm.InstallZipLib(ctx, entry.ZipPath, entry.ZipSHA256)
+	default:
+		release := lm.Index.FindIndexedLibrary(entry.Name).GetRelease(entry.Version)
+		if release == nil {
+			return fmt.Errorf("version %s of %s not found in any configured index", entry.Version, entry.Name)
+		}
+		libPath, _, err := lm.InstallPrerequisiteCheck(release)
+		if err == ErrAlreadyInstalled {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		return lm.Install(release, libPath)
+	}
+}
@@ -0,0 +1,155 @@
+// This file is part of arduino-cli.
+//
+// Copyright 2020 ARDUINO SA (http://www.arduino.cc/)
+//
+// This software is released under the GNU General Public License version 3,
+// which covers the main part of arduino-cli.
+// The terms of this license can be found at:
+// https://www.gnu.org/licenses/gpl-3.0.en.html
+//
+// You can be released from the requirements of the above licenses by purchasing
+// a commercial license. Buying such a license is mandatory if you want to
+// modify or otherwise use the software for commercial activities involving the
+// Arduino software without disclosing the source code of your own applications.
+// To purchase a commercial license, send an email to license@arduino.cc.
+
+package librariesmanager
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/arduino/arduino-cli/arduino/libraries/librariesindex"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	semver "go.bug.st/relaxed-semver"
+)
+
+func releaseAt(version string) *librariesindex.Release {
+	return &librariesindex.Release{Version: semver.ParseRelaxed(version)}
+}
+
+func TestPickBestReleasePicksHighestSatisfyingVersion(t *testing.T) {
+	releases := []*librariesindex.Release{releaseAt("1.0.0"), releaseAt("1.2.0"), releaseAt("2.0.0")}
+	reqs := []requirement{{constraint: "^1.0.0", origin: "manifest"}}
+
+	best, chain := pickBestRelease(releases, reqs)
+
+	require.NotNil(t, best)
+	assert.Nil(t, chain)
+	assert.Equal(t, "1.2.0", best.Version.String())
+}
+
+func TestPickBestReleaseIsDeterministic(t *testing.T) {
+	releases := []*librariesindex.Release{releaseAt("1.2.0"), releaseAt("1.10.0"), releaseAt("1.3.0")}
+
+	for i := 0; i < 10; i++ {
+		best, _ := pickBestRelease(releases, nil)
+		require.NotNil(t, best)
+		assert.Equal(t, "1.10.0", best.Version.String())
+	}
+}
+
+func TestPickBestReleaseReportsConflictChain(t *testing.T) {
+	releases := []*librariesindex.Release{releaseAt("1.0.0"), releaseAt("2.0.0")}
+	reqs := []requirement{
+		{constraint: ">=2.0.0", origin: "manifest"},
+		{constraint: "<2.0.0", origin: "OtherLib"},
+	}
+
+	best, chain := pickBestRelease(releases, reqs)
+
+	assert.Nil(t, best)
+	require.Len(t, chain, 2)
+	assert.Contains(t, chain[0], ">=2.0.0")
+	assert.Contains(t, chain[0], "manifest")
+	assert.Contains(t, chain[1], "<2.0.0")
+	assert.Contains(t, chain[1], "OtherLib")
+
+	err := &ErrDependencyConflict{Library: "SomeLib", Chain: chain}
+	assert.True(t, strings.Contains(err.Error(), "SomeLib"))
+	assert.True(t, strings.Contains(err.Error(), ">=2.0.0 (required by manifest)"))
+}
+
+func TestVersionSatisfies(t *testing.T) {
+	cases := []struct {
+		version, constraint string
+		want                bool
+	}{
+		{"1.2.3", "*", true},
+		{"1.2.3", "1.2.3", true},
+		{"1.2.3", "1.2.4", false},
+		{"1.2.3", ">=1.2.0", true},
+		{"1.2.3", ">=1.3.0", false},
+		{"1.2.3", "<=1.2.3", true},
+		{"1.2.3", "<1.2.3", false},
+		{"1.2.3", "^1.0.0", true},
+		{"2.0.0", "^1.0.0", false},
+	}
+	for _, c := range cases {
+		assert.Equal(t, c.want, versionSatisfies(c.version, c.constraint), "version=%s constraint=%s", c.version, c.constraint)
+	}
+}
+
+func TestResolveManifestHonorsPinForTransitiveDependency(t *testing.T) {
+	libA := &librariesindex.Library{
+		Name: "LibA",
+		Releases: map[string]*librariesindex.Release{
+			"1.0.0": {
+				Version:      semver.ParseRelaxed("1.0.0"),
+				Dependencies: []*librariesindex.Dependency{{Name: "LibC", VersionConstraint: ">=1.0.0"}},
+			},
+		},
+	}
+
+	lm := &LibrariesManager{Index: &librariesindex.Index{Libraries: map[string][]*librariesindex.Library{
+		"LibA": {libA},
+	}}}
+
+	// LibC is never in any configured index: it's only reachable here
+	// through the git pin. Resolving LibA's depends= entry for LibC must
+	// defer to that pin instead of trying (and failing) to find LibC in
+	// lm.Index.
+	manifest := &Manifest{Libraries: []*ManifestLibrary{
+		{Name: "LibA"},
+		{Name: "LibC", GitURL: "https://example.com/libc.git", Ref: "v1.0.0"},
+	}}
+
+	lock, err := lm.ResolveManifest(context.Background(), manifest)
+	require.NoError(t, err)
+
+	byName := map[string]*LockedLibrary{}
+	for _, entry := range lock.Libraries {
+		byName[entry.Name] = entry
+	}
+	require.Contains(t, byName, "LibA")
+	require.Contains(t, byName, "LibC")
+	assert.Equal(t, "1.0.0", byName["LibA"].Version)
+	assert.Equal(t, "https://example.com/libc.git", byName["LibC"].GitURL)
+	assert.Equal(t, "v1.0.0", byName["LibC"].GitSHA)
+}
+
+func TestCheckGitPin(t *testing.T) {
+	assert.NoError(t, checkGitPin("MyLib", "", "abc123"))
+	assert.NoError(t, checkGitPin("MyLib", "abc123", "ABC123"))
+
+	err := checkGitPin("MyLib", "abc123", "def456")
+	require.Error(t, err)
+	assert.ErrorIs(t, err, ErrIntegrityMismatch)
+	assert.Contains(t, err.Error(), "MyLib")
+	assert.Contains(t, err.Error(), "abc123")
+	assert.Contains(t, err.Error(), "def456")
+}
+
+func TestSha256OfFile(t *testing.T) {
+	dir := t.TempDir()
+	path := dir + "/archive.zip"
+	require.NoError(t, os.WriteFile(path, []byte("hello world"), 0o644))
+
+	sum, err := sha256OfFile(path)
+	require.NoError(t, err)
+	// sha256("hello world")
+	assert.Equal(t, "b94d27b9934d3e08a52e52d7da7dabfac484efe37a5380ee9088f7ace2efcde", sum)
+}
@@ -0,0 +1,127 @@
+// This file is part of arduino-cli.
+//
+// Copyright 2020 ARDUINO SA (http://www.arduino.cc/)
+//
+// This software is released under the GNU General Public License version 3,
+// which covers the main part of arduino-cli.
+// The terms of this license can be found at:
+// https://www.gnu.org/licenses/gpl-3.0.en.html
+//
+// You can be released from the requirements of the above licenses by purchasing
+// a commercial license. Buying such a license is mandatory if you want to
+// modify or otherwise use the software for commercial activities involving the
+// Arduino software without disclosing the source code of your own applications.
+// To purchase a commercial license, send an email to license@arduino.cc.
+
+package librariesmanager
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/arduino/arduino-cli/arduino/libraries/librariesindex"
+	"github.com/arduino/arduino-cli/arduino/security"
+	paths "github.com/arduino/go-paths-helper"
+)
+
+// LibraryRepository is a single configured third-party library index,
+// fetched into its own cache directory and merged into lm.Index alongside
+// the official Arduino index. TrustedKeysFile, when set, is an armored GPG
+// keyring the repo's detached signature (fetched from URL + ".sig") must
+// verify against before the index is trusted.
+type LibraryRepository struct {
+	Name            string
+	URL             string
+	TrustedKeysFile *paths.Path
+}
+
+// AddRepository registers name/url as an additional library index source.
+// It fails if a repository with the same name is already configured; the
+// caller is expected to persist the updated repository list alongside the
+// rest of the configuration.
+func (lm *LibrariesManager) AddRepository(name, url string, trustedKeysFile *paths.Path) error {
+	for _, repo := range lm.Repositories {
+		if repo.Name == name {
+			return fmt.Errorf("a library repository named %s is already configured", name)
+		}
+	}
+	lm.Repositories = append(lm.Repositories, &LibraryRepository{Name: name, URL: url, TrustedKeysFile: trustedKeysFile})
+	return nil
+}
+
+// RemoveRepository unregisters a previously added third-party library index.
+func (lm *LibrariesManager) RemoveRepository(name string) error {
+	for i, repo := range lm.Repositories {
+		if repo.Name == name {
+			lm.Repositories = append(lm.Repositories[:i], lm.Repositories[i+1:]...)
+			return nil
+		}
+	}
+	return fmt.Errorf("no library repository named %s is configured", name)
+}
+
+// UpdateIndexes fetches every configured repository's index into its own
+// cache directory under lm.IndexesDir, verifies it when a trusted keyring
+// was configured for that repository, and merges the result into lm.Index.
+func (lm *LibrariesManager) UpdateIndexes() error {
+	for _, repo := range lm.Repositories {
+		if err := lm.updateIndex(repo); err != nil {
+			return fmt.Errorf("updating library repository %s: %w", repo.Name, err)
+		}
+	}
+	return nil
+}
+
+func (lm *LibrariesManager) updateIndex(repo *LibraryRepository) error {
+	cacheDir := lm.IndexesDir.Join(repo.Name)
+	if err := cacheDir.MkdirAll(); err != nil {
+		return err
+	}
+
+	indexFile := cacheDir.Join("library_index.json")
+	if err := downloadFile(indexFile, repo.URL); err != nil {
+		return err
+	}
+
+	if repo.TrustedKeysFile != nil {
+		sigFile := cacheDir.Join("library_index.json.sig")
+		if err := downloadFile(sigFile, repo.URL+".sig"); err != nil {
+			return err
+		}
+		valid, _, err := security.VerifyDetachedSignature(indexFile, sigFile, repo.TrustedKeysFile)
+		if err != nil {
+			return fmt.Errorf("verifying signature: %w", err)
+		}
+		if !valid {
+			return fmt.Errorf("index failed signature verification against the configured keyring, refusing to trust it")
+		}
+	}
+
+	index, err := librariesindex.LoadIndex(indexFile)
+	if err != nil {
+		return fmt.Errorf("parsing index: %w", err)
+	}
+	lm.Index.Merge(index, repo.Name)
+	return nil
+}
+
+// downloadFile fetches url and writes its body to dest, overwriting it.
+func downloadFile(dest *paths.Path, url string) error {
+	resp, err := http.Get(url)
+	if err != nil {
+		return fmt.Errorf("downloading %s: %w", url, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("downloading %s: server returned %s", url, resp.Status)
+	}
+
+	out, err := dest.Create()
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+	_, err = io.Copy(out, resp.Body)
+	return err
+}
@@ -0,0 +1,123 @@
+// This file is part of arduino-cli.
+//
+// Copyright 2020 ARDUINO SA (http://www.arduino.cc/)
+//
+// This software is released under the GNU General Public License version 3,
+// which covers the main part of arduino-cli.
+// The terms of this license can be found at:
+// https://www.gnu.org/licenses/gpl-3.0.en.html
+//
+// You can be released from the requirements of the above licenses by purchasing
+// a commercial license. Buying such a license is mandatory if you want to
+// modify or otherwise use the software for commercial activities involving the
+// Arduino software without disclosing the source code of your own applications.
+// To purchase a commercial license, send an email to license@arduino.cc.
+
+package librariesmanager
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/arduino/arduino-cli/arduino/libraries/librariesindex"
+	paths "github.com/arduino/go-paths-helper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAddRepositoryRejectsDuplicateName(t *testing.T) {
+	lm := &LibrariesManager{}
+	require.NoError(t, lm.AddRepository("community", "https://example.com/library_index.json", nil))
+
+	err := lm.AddRepository("community", "https://example.com/other.json", nil)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "community")
+	assert.Len(t, lm.Repositories, 1)
+}
+
+func TestRemoveRepositoryRemovesByNameAndErrorsWhenMissing(t *testing.T) {
+	lm := &LibrariesManager{}
+	require.NoError(t, lm.AddRepository("community", "https://example.com/a.json", nil))
+	require.NoError(t, lm.AddRepository("other", "https://example.com/b.json", nil))
+
+	require.NoError(t, lm.RemoveRepository("community"))
+	require.Len(t, lm.Repositories, 1)
+	assert.Equal(t, "other", lm.Repositories[0].Name)
+
+	err := lm.RemoveRepository("community")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "community")
+}
+
+func TestUpdateIndexesCachesIntoItsOwnDirAndWrapsErrorsWithRepoName(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.NotFound(w, r)
+	}))
+	defer server.Close()
+
+	indexesDir := paths.New(t.TempDir())
+	lm := &LibrariesManager{IndexesDir: indexesDir, Index: &librariesindex.Index{}}
+	require.NoError(t, lm.AddRepository("community", server.URL+"/library_index.json", nil))
+
+	err := lm.UpdateIndexes()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "community")
+	assert.True(t, indexesDir.Join("community").IsDir(), "updateIndex should create a cache dir under IndexesDir named after the repository")
+}
+
+func TestUpdateIndexMergesValidIndexWithSource(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/library_index.json", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{
+			"libraries": [
+				{"name": "Servo", "version": "1.0.0"},
+				{"name": "Servo", "version": "1.1.0", "dependencies": [{"name": "LibC", "version": ">=2.0.0"}]}
+			]
+		}`))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	lm := &LibrariesManager{IndexesDir: paths.New(t.TempDir()), Index: &librariesindex.Index{}}
+	require.NoError(t, lm.AddRepository("community", server.URL+"/library_index.json", nil))
+
+	require.NoError(t, lm.UpdateIndexes())
+
+	lib := lm.Index.FindIndexedLibraryFromRepo("Servo", "community")
+	require.NotNil(t, lib)
+	assert.Equal(t, "community", lib.Source)
+	require.NotNil(t, lib.GetRelease("1.0.0"))
+	assert.Equal(t, "community", lib.GetRelease("1.0.0").Source)
+
+	latest := lib.GetRelease("1.1.0")
+	require.NotNil(t, latest)
+	require.Len(t, latest.Dependencies, 1)
+	assert.Equal(t, "LibC", latest.Dependencies[0].Name)
+	assert.Equal(t, ">=2.0.0", latest.Dependencies[0].VersionConstraint)
+	assert.Same(t, latest, lib.Latest, "the higher of the two merged releases should be Latest")
+}
+
+func TestUpdateIndexFailsClearlyWhenSignatureIsMissing(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/library_index.json", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{}`))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	indexesDir := paths.New(t.TempDir())
+	keyring := paths.New(t.TempDir()).Join("keyring.gpg")
+	require.NoError(t, keyring.WriteFile([]byte("not a real keyring")))
+
+	lm := &LibrariesManager{IndexesDir: indexesDir, Index: &librariesindex.Index{}}
+	require.NoError(t, lm.AddRepository("community", server.URL+"/library_index.json", keyring))
+
+	// A repository configured with a trusted keyring but that fails to
+	// publish a matching .sig must not fall back to trusting the index
+	// unverified.
+	err := lm.UpdateIndexes()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "community")
+	assert.Contains(t, err.Error(), ".sig")
+}